@@ -2,13 +2,13 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -20,8 +20,9 @@ import (
 // Configuration data with defaults:
 
 const (
-	projectName          = "arangodb"
-	defaultDockerGCDelay = time.Minute * 10
+	projectName            = "arangodb"
+	defaultDockerGCDelay   = time.Minute * 10
+	defaultShutdownTimeout = time.Second * 30
 )
 
 var (
@@ -32,27 +33,40 @@ var (
 		Short: "Start ArangoDB clusters with ease",
 		Run:   cmdMainRun,
 	}
-	log               = logging.MustGetLogger(projectName)
-	id                string
-	agencySize        int
-	arangodExecutable string
-	arangodJSstartup  string
-	masterPort        int
-	rrPath            string
-	startCoordinator  bool
-	startDBserver     bool
-	dataDir           string
-	ownAddress        string
-	masterAddress     string
-	verbose           bool
-	serverThreads     int
-	dockerEndpoint    string
-	dockerImage       string
-	dockerUser        string
-	dockerContainer   string
-	dockerGCDelay     time.Duration
-	dockerNetHost     bool
-	dockerPrivileged  bool
+	log                 = logging.MustGetLogger(projectName)
+	id                  string
+	agencySize          int
+	arangodExecutable   string
+	arangodJSstartup    string
+	masterPort          int
+	rrPath              string
+	startCoordinator    bool
+	startDBserver       bool
+	dataDir             string
+	ownAddress          string
+	masterAddress       string
+	verbose             bool
+	serverThreads       int
+	dockerEndpoint      string
+	dockerImage         string
+	dockerUser          string
+	dockerContainer     string
+	dockerGCDelay       time.Duration
+	dockerNetHost       bool
+	dockerPrivileged    bool
+	containerRuntime    string
+	containerdEndpoint  string
+	containerdNamespace string
+	discovery           string
+	shutdownTimeout     time.Duration
+	tlsCertFile         string
+	tlsKeyFile          string
+	tlsCAFile           string
+	tlsClientAuth       string
+	tlsAutoGenerate     bool
+	authJWTSecret       string
+	metricsAddr         string
+	metricsGoCollector  bool
 )
 
 func init() {
@@ -77,18 +91,41 @@ func init() {
 	f.DurationVar(&dockerGCDelay, "dockerGCDelay", defaultDockerGCDelay, "Delay before stopped containers are garbage collected")
 	f.BoolVar(&dockerNetHost, "dockerNetHost", false, "Run containers with --net=host")
 	f.BoolVar(&dockerPrivileged, "dockerPrivileged", false, "Run containers with --privileged")
+	f.StringVar(&containerRuntime, "containerRuntime", "docker", "Container runtime to use to launch arangod instances (docker|containerd|process)")
+	f.StringVar(&containerdEndpoint, "containerdEndpoint", "/run/containerd/containerd.sock", "Endpoint used to reach the containerd daemon")
+	f.StringVar(&containerdNamespace, "containerdNamespace", "arangodb", "containerd namespace to create containers in")
+	f.StringVar(&discovery, "discovery", "", "Service discovery URL used to find peers instead of --join, e.g. etcd://host:2379/arangodb/<cluster-id> or consul://host:8500/arangodb/<cluster-id>")
+	f.DurationVar(&shutdownTimeout, "shutdownTimeout", defaultShutdownTimeout, "Time allowed for child processes to stop gracefully before they are forced down")
+	f.StringVar(&tlsCertFile, "tls.cert", "", "TLS certificate used by the peer coordination HTTP server")
+	f.StringVar(&tlsKeyFile, "tls.key", "", "TLS private key used by the peer coordination HTTP server")
+	f.StringVar(&tlsCAFile, "tls.ca", "", "TLS CA certificate used to verify peer certificates")
+	f.StringVar(&tlsClientAuth, "tls.clientAuth", "none", "Client certificate verification mode: none|require")
+	f.BoolVar(&tlsAutoGenerate, "tls.autoGenerate", false, "Create a self-signed CA and per-peer certificates in --dataDir on first run")
+	f.StringVar(&authJWTSecret, "auth.jwtSecret", getEnvVar("AUTH_KEY", ""), "Shared secret used to authenticate mutating requests between starters")
+	f.StringVar(&metricsAddr, "metrics.addr", "", "Bind address for a separate /metrics listener (leave empty to serve /metrics on the coordination port)")
+	f.BoolVar(&metricsGoCollector, "metrics.goCollector", false, "Also expose the standard Go runtime/process metrics on /metrics")
 }
 
-// handleSignal listens for termination signals and stops this process onup termination.
-func handleSignal(sigChannel chan os.Signal, cancel context.CancelFunc) {
-	signalCount := 0
+// handleSignal listens for termination signals and gives the service a
+// chance to shut down cleanly before forcing it down:
+//   - 1st signal: start a graceful shutdown (send /goodbye, stop children,
+//     save setup), bounded by --shutdownTimeout.
+//   - 2nd signal: stop waiting and SIGTERM the children directly.
+//   - 3rd signal: exit immediately, bypassing all cleanup.
+func handleSignal(sigChannel chan os.Signal, cancel context.CancelFunc, svc *service.Service) {
+	var signalCount int32
 	for s := range sigChannel {
-		signalCount++
-		fmt.Println("Received signal:", s)
-		if signalCount > 1 {
+		switch atomic.AddInt32(&signalCount, 1) {
+		case 1:
+			log.Infof("Received signal %s, graceful shutdown started", s)
+			cancel()
+			go svc.GracefulStop(shutdownTimeout)
+		case 2:
+			log.Infof("Received signal %s, forcing shutdown", s)
+			svc.HardStop()
+		default:
 			os.Exit(1)
 		}
-		cancel()
 	}
 }
 
@@ -193,37 +230,51 @@ func cmdMainRun(cmd *cobra.Command, args []string) {
 	sigChannel := make(chan os.Signal)
 	rootCtx, cancel := context.WithCancel(context.Background())
 	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
-	go handleSignal(sigChannel, cancel)
 
 	// Create service
 	service, err := service.NewService(log, service.ServiceConfig{
-		ID:                id,
-		AgencySize:        agencySize,
-		ArangodExecutable: arangodExecutable,
-		ArangodJSstartup:  arangodJSstartup,
-		MasterPort:        masterPort,
-		RrPath:            rrPath,
-		StartCoordinator:  startCoordinator,
-		StartDBserver:     startDBserver,
-		DataDir:           dataDir,
-		OwnAddress:        ownAddress,
-		MasterAddress:     masterAddress,
-		Verbose:           verbose,
-		ServerThreads:     serverThreads,
-		RunningInDocker:   os.Getenv("RUNNING_IN_DOCKER") == "true",
-		DockerContainer:   dockerContainer,
-		DockerEndpoint:    dockerEndpoint,
-		DockerImage:       dockerImage,
-		DockerUser:        dockerUser,
-		DockerGCDelay:     dockerGCDelay,
-		DockerNetHost:     dockerNetHost,
-		DockerPrivileged:  dockerPrivileged,
-		ProjectVersion:    projectVersion,
-		ProjectBuild:      projectBuild,
+		ID:                  id,
+		AgencySize:          agencySize,
+		ArangodExecutable:   arangodExecutable,
+		ArangodJSstartup:    arangodJSstartup,
+		MasterPort:          masterPort,
+		RrPath:              rrPath,
+		StartCoordinator:    startCoordinator,
+		StartDBserver:       startDBserver,
+		DataDir:             dataDir,
+		OwnAddress:          ownAddress,
+		MasterAddress:       masterAddress,
+		Verbose:             verbose,
+		ServerThreads:       serverThreads,
+		RunningInDocker:     os.Getenv("RUNNING_IN_DOCKER") == "true",
+		DockerContainer:     dockerContainer,
+		DockerEndpoint:      dockerEndpoint,
+		DockerImage:         dockerImage,
+		DockerUser:          dockerUser,
+		DockerGCDelay:       dockerGCDelay,
+		DockerNetHost:       dockerNetHost,
+		DockerPrivileged:    dockerPrivileged,
+		ContainerRuntime:    containerRuntime,
+		ContainerdEndpoint:  containerdEndpoint,
+		ContainerdNamespace: containerdNamespace,
+		Discovery:           discovery,
+		TLSConfig: service.TLSConfig{
+			CertFile:     tlsCertFile,
+			KeyFile:      tlsKeyFile,
+			CAFile:       tlsCAFile,
+			ClientAuth:   tlsClientAuth,
+			AutoGenerate: tlsAutoGenerate,
+		},
+		AuthJWTSecret:      authJWTSecret,
+		MetricsAddr:        metricsAddr,
+		MetricsGoCollector: metricsGoCollector,
+		ProjectVersion:     projectVersion,
+		ProjectBuild:       projectBuild,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create service: %#v", err)
 	}
+	go handleSignal(sigChannel, cancel, service)
 
 	// Run the service
 	service.Run(rootCtx)
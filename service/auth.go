@@ -0,0 +1,37 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// requireBearerToken wraps handler so that it rejects any request whose
+// `Authorization: Bearer <token>` header does not match a token derived
+// from secret, matching the pattern external ArangoDB clusters already use
+// for controller auth (--auth.jwtSecret / AUTH_KEY).
+func requireBearerToken(secret string, handler http.HandlerFunc) http.HandlerFunc {
+	if secret == "" {
+		return handler
+	}
+	expected := bearerToken(secret)
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header || !hmac.Equal([]byte(token), []byte(expected)) {
+			writeError(w, http.StatusUnauthorized, "Invalid or missing bearer token")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// bearerToken derives a stable token from secret, so the same --auth.jwtSecret
+// (or AUTH_KEY) always yields the same value across all starters in a cluster.
+func bearerToken(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("arangodb-starter"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+const childMonitorInterval = time.Second * 5
+
+// startChildMonitorLoop periodically checks whether the agent/coordinator/
+// dbserver processes started by this peer are still alive, keeps
+// arangodb_child_up current on every tick (not just on /process scrapes),
+// and records transitions: a process that was up and is now gone counts as
+// a restart (arangodb_child_restarts_total) with its last known exit code in
+// arangodb_child_last_exit_code. It runs until stop is closed.
+func (s *Service) startChildMonitorLoop(stop <-chan struct{}) {
+	wasUp := map[string]bool{"agent": false, "coordinator": false, "dbserver": false}
+
+	ticker := time.NewTicker(childMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, proc := range []struct {
+				kind string
+				p    Container
+			}{
+				{"agent", s.servers.agentProc},
+				{"coordinator", s.servers.coordinatorProc},
+				{"dbserver", s.servers.dbserverProc},
+			} {
+				if proc.p == nil {
+					s.metrics.childUp.WithLabelValues(proc.kind).Set(0)
+					continue
+				}
+				up, exitCode := s.childIsUp(proc.p)
+				if wasUp[proc.kind] && !up {
+					s.metrics.childRestarts.WithLabelValues(proc.kind).Inc()
+					s.metrics.childLastExit.WithLabelValues(proc.kind).Set(float64(exitCode))
+					s.log.Warningf("Child process '%s' is no longer running (exit code %d)", proc.kind, exitCode)
+				}
+				wasUp[proc.kind] = up
+				if up {
+					s.metrics.childUp.WithLabelValues(proc.kind).Set(1)
+				} else {
+					s.metrics.childUp.WithLabelValues(proc.kind).Set(0)
+				}
+			}
+		}
+	}
+}
+
+// childIsUp reports whether p's underlying process or container is still
+// running, and the exit code it last observed (0 when still running or
+// unknown).
+func (s *Service) childIsUp(p Container) (bool, int) {
+	if containerID := p.ContainerID(); containerID != "" {
+		rt, err := s.containerRuntime()
+		if err != nil || rt == nil {
+			return false, 0
+		}
+		info, err := rt.Inspect(containerID)
+		if err != nil {
+			return false, 0
+		}
+		return info.Running, info.ExitCode
+	}
+
+	pid := p.ProcessID()
+	if pid <= 0 {
+		return false, 0
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false, 0
+	}
+	// On unix, FindProcess always succeeds; signal 0 is the portable way to
+	// probe whether the process is still alive without actually signalling it.
+	if err := process.Signal(syscall.Signal(0)); err != nil {
+		return false, 0
+	}
+	return true, 0
+}
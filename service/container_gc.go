@@ -0,0 +1,91 @@
+package service
+
+import "time"
+
+// containerRuntime lazily builds (and caches) the ContainerRuntime selected
+// by --containerRuntime. It returns nil, nil for the "process" runtime,
+// since servers started as plain OS processes have nothing to garbage
+// collect through this interface. It is safe to call concurrently (from the
+// GC loop and the child monitor loop alike): the build only ever runs once,
+// via s.containerRuntimeOnce.
+func (s *Service) containerRuntime() (ContainerRuntime, error) {
+	s.containerRuntimeOnce.Do(func() {
+		s.cachedContainerRuntime, s.containerRuntimeErr = NewContainerRuntime(s.ContainerRuntime, s.ServiceConfig)
+	})
+	if s.containerRuntimeErr != nil {
+		return nil, maskAny(s.containerRuntimeErr)
+	}
+	return s.cachedContainerRuntime, nil
+}
+
+// startContainerGCLoop periodically reaps containers backing stopped
+// agent/coordinator/dbserver servers, regardless of whether they are
+// running under Docker or containerd, replacing the old Docker-only GC
+// loop driven by --dockerGCDelay.
+func (s *Service) startContainerGCLoop(stop <-chan struct{}) {
+	rt, err := s.containerRuntime()
+	if err != nil {
+		s.log.Errorf("Failed to create container runtime: %#v", err)
+		return
+	}
+	if rt == nil {
+		// --containerRuntime=process: nothing to garbage collect.
+		return
+	}
+
+	ticker := time.NewTicker(s.DockerGCDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, p := range s.runningContainers() {
+				s.gcContainer(rt, p)
+			}
+		}
+	}
+}
+
+// runningContainers returns the ContainerIDs of the servers started by this
+// peer that are backed by a container (i.e. ContainerID() is non-empty),
+// the same fields processListHandler already reads.
+func (s *Service) runningContainers() []string {
+	var ids []string
+	if p := s.servers.agentProc; p != nil {
+		if id := p.ContainerID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if p := s.servers.coordinatorProc; p != nil {
+		if id := p.ContainerID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if p := s.servers.dbserverProc; p != nil {
+		if id := p.ContainerID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// gcContainer removes containerID through rt if the runtime reports it as no
+// longer running. It is called once per DockerGCDelay tick, so a stopped
+// container is removed at the first tick after it stopped, i.e. within
+// DockerGCDelay of stopping.
+func (s *Service) gcContainer(rt ContainerRuntime, containerID string) {
+	info, err := rt.Inspect(containerID)
+	if err != nil {
+		s.log.Debugf("Failed to inspect container '%s': %#v", containerID, err)
+		return
+	}
+	if info.Running {
+		return
+	}
+	if err := rt.Remove(containerID); err != nil {
+		s.log.Warningf("Failed to garbage collect container '%s': %#v", containerID, err)
+		return
+	}
+	s.log.Infof("Garbage collected stopped container '%s'", containerID)
+}
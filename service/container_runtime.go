@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// ContainerConfig describes everything a ContainerRuntime needs in order to
+// create a container that runs a single arangod server.
+type ContainerConfig struct {
+	Image       string            // Docker/OCI image to run
+	Command     string            // Executable to run inside the container
+	Args        []string          // Arguments passed to Command
+	Volumes     map[string]string // host path -> container path bind mounts
+	Ports       map[int]int       // container port -> host port
+	User        string            // User to run the container as (empty means image default)
+	NetHost     bool              // Run the container with host networking
+	Privileged  bool              // Run the container in privileged mode
+	ContainerID string            // Pre-set name/ID to assign to the container (optional)
+}
+
+// ContainerInfo reflects the runtime's view of a container's current state.
+type ContainerInfo struct {
+	ContainerID string
+	Running     bool
+	ExitCode    int
+}
+
+// Container is a handle on a container created through a ContainerRuntime.
+// It satisfies the same ProcessID/ContainerID contract that processListHandler
+// already uses for servers started directly as an OS process.
+type Container interface {
+	ProcessID() int
+	ContainerID() string
+}
+
+// ContainerRuntime abstracts away the backend used to run arangod servers as
+// containers, so the Service does not have to know whether it is talking to
+// the Docker daemon, containerd, or anything else.
+type ContainerRuntime interface {
+	// Create creates (but does not start) a container for the given config.
+	Create(config ContainerConfig) (Container, error)
+	// Start starts a previously created container.
+	Start(containerID string) error
+	// Stop stops a running container, giving it up to timeout to terminate
+	// before it is killed.
+	Stop(containerID string, timeout time.Duration) error
+	// Inspect returns the current state of a container.
+	Inspect(containerID string) (ContainerInfo, error)
+	// Logs returns a reader over the container's combined stdout/stderr.
+	Logs(containerID string) (io.ReadCloser, error)
+	// Remove removes a stopped container.
+	Remove(containerID string) error
+}
+
+// NewContainerRuntime creates the ContainerRuntime implementation selected by
+// the given kind ("docker", "containerd" or "process").
+func NewContainerRuntime(kind string, config ServiceConfig) (ContainerRuntime, error) {
+	switch kind {
+	case "", "docker":
+		return newDockerRuntime(config)
+	case "containerd":
+		return newContainerdRuntime(config)
+	case "process":
+		return nil, nil
+	default:
+		return nil, maskAny(fmt.Errorf("Unknown container runtime '%s'", kind))
+	}
+}
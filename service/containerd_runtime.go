@@ -0,0 +1,182 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/namespaces"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultContainerdNamespace = "arangodb"
+)
+
+// containerdTask wraps a containerd task so it satisfies the Container
+// interface used throughout the service package.
+type containerdTask struct {
+	task containerd.Task
+}
+
+func (t *containerdTask) ProcessID() int {
+	return int(t.task.Pid())
+}
+
+func (t *containerdTask) ContainerID() string {
+	return t.task.ID()
+}
+
+// containerdRuntime implements ContainerRuntime on top of a containerd gRPC
+// endpoint (containerd.sock).
+type containerdRuntime struct {
+	client    *containerd.Client
+	namespace string
+}
+
+// newContainerdRuntime creates a ContainerRuntime that talks to containerd
+// over the endpoint and namespace configured on config.
+func newContainerdRuntime(config ServiceConfig) (ContainerRuntime, error) {
+	endpoint := config.ContainerdEndpoint
+	if endpoint == "" {
+		endpoint = "/run/containerd/containerd.sock"
+	}
+	namespace := config.ContainerdNamespace
+	if namespace == "" {
+		namespace = defaultContainerdNamespace
+	}
+	client, err := containerd.New(endpoint)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &containerdRuntime{
+		client:    client,
+		namespace: namespace,
+	}, nil
+}
+
+func (r *containerdRuntime) ctx() context.Context {
+	return namespaces.WithNamespace(context.Background(), r.namespace)
+}
+
+// Create pulls the configured image (if needed), creates an OCI runtime spec
+// from config and creates (but does not start) the resulting task.
+func (r *containerdRuntime) Create(config ContainerConfig) (Container, error) {
+	ctx := r.ctx()
+	image, err := r.client.Pull(ctx, config.Image, containerd.WithPullUnpack)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	id := config.ContainerID
+	if id == "" {
+		id = fmt.Sprintf("arangod-%d", time.Now().UnixNano())
+	}
+	args := append([]string{config.Command}, config.Args...)
+	container, err := r.client.NewContainer(ctx, id,
+		containerd.WithNewSnapshot(id+"-snapshot", image),
+		containerd.WithNewSpec(containerd.WithImageConfig(image), containerd.WithProcessArgs(args...)),
+	)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	task, err := container.NewTask(ctx, cio.NullIO)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &containerdTask{task: task}, nil
+}
+
+// Start starts a previously created task.
+func (r *containerdRuntime) Start(containerID string) error {
+	ctx := r.ctx()
+	container, err := r.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return maskAny(err)
+	}
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := task.Start(ctx); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Stop sends SIGTERM to the task and, if it has not exited within timeout,
+// SIGKILL, then waits for it to exit.
+func (r *containerdRuntime) Stop(containerID string, timeout time.Duration) error {
+	ctx := r.ctx()
+	container, err := r.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return maskAny(err)
+	}
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	statusC, err := task.Wait(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := task.Kill(ctx, 15); err != nil { // SIGTERM
+		return maskAny(err)
+	}
+	select {
+	case <-statusC:
+	case <-time.After(timeout):
+		if err := task.Kill(ctx, 9); err != nil { // SIGKILL
+			return maskAny(err)
+		}
+		<-statusC
+	}
+	return nil
+}
+
+// Inspect returns the current state of the containerd task.
+func (r *containerdRuntime) Inspect(containerID string) (ContainerInfo, error) {
+	ctx := r.ctx()
+	container, err := r.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return ContainerInfo{}, maskAny(err)
+	}
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return ContainerInfo{}, maskAny(err)
+	}
+	status, err := task.Status(ctx)
+	if err != nil {
+		return ContainerInfo{}, maskAny(err)
+	}
+	return ContainerInfo{
+		ContainerID: containerID,
+		Running:     status.Status == containerd.Running,
+		ExitCode:    int(status.ExitStatus),
+	}, nil
+}
+
+// Logs is not supported for containerd tasks, whose output is discarded
+// (Create uses cio.NullIO); it always returns an error.
+func (r *containerdRuntime) Logs(containerID string) (io.ReadCloser, error) {
+	return nil, maskAny(fmt.Errorf("Logs are not yet supported for the containerd runtime, use the persisted arangod.log file instead"))
+}
+
+// Remove deletes a stopped task and its container.
+func (r *containerdRuntime) Remove(containerID string) error {
+	ctx := r.ctx()
+	container, err := r.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return maskAny(err)
+	}
+	if task, err := container.Task(ctx, nil); err == nil {
+		if _, err := task.Delete(ctx); err != nil {
+			return maskAny(err)
+		}
+	}
+	if err := container.Delete(ctx, containerd.WithSnapshotCleanup); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
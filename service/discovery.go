@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DiscoveryPeer is the information a PeerDiscovery backend registers and
+// watches for, enough to bootstrap a /hello exchange without a manual --join.
+type DiscoveryPeer struct {
+	ID       string // Unique ID of the peer
+	Address  string // Address under which the peer is reachable
+	Port     int    // Port under which the peer is reachable
+	DataDir  string // Directory used for data by this peer
+	HasAgent bool   // Is this peer willing to run an agent
+}
+
+// PeerDiscovery abstracts the service-discovery backend used to find other
+// starters in the cluster, so a slave no longer has to be told the master's
+// address via --join.
+type PeerDiscovery interface {
+	// Register announces this peer under the cluster key and keeps the
+	// registration alive until Deregister is called.
+	Register(self DiscoveryPeer) error
+	// Deregister removes this peer's registration.
+	Deregister(id string) error
+	// Watch returns a channel that first receives the currently known peers
+	// and then an updated list whenever the registry reports a change. The
+	// channel is closed once stop is closed.
+	Watch(stop <-chan struct{}) (<-chan []DiscoveryPeer, error)
+}
+
+// NewPeerDiscovery creates a PeerDiscovery implementation from a discovery
+// URL such as `etcd://host:2379/arangodb/<cluster-id>` or
+// `consul://host:8500/arangodb/<cluster-id>`. An empty url disables
+// discovery, in which case nil is returned.
+func NewPeerDiscovery(discoveryURL string) (PeerDiscovery, error) {
+	if discoveryURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(discoveryURL)
+	if err != nil {
+		return nil, maskAny(fmt.Errorf("Invalid --discovery URL '%s': %v", discoveryURL, err))
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return nil, maskAny(fmt.Errorf("--discovery URL '%s' must include a cluster key path", discoveryURL))
+	}
+	switch u.Scheme {
+	case "etcd":
+		return newEtcdDiscovery(u.Host, key)
+	case "consul":
+		return newConsulDiscovery(u.Host, key)
+	default:
+		return nil, maskAny(fmt.Errorf("Unknown discovery scheme '%s'", u.Scheme))
+	}
+}
+
+// electMaster returns the DiscoveryPeer with the lexicographically smallest
+// ID, which becomes the initial master, i.e. the peer that accepts /hello
+// and assigns PortOffsets, until AgencySize peers have registered.
+func electMaster(peers []DiscoveryPeer) (DiscoveryPeer, bool) {
+	if len(peers) == 0 {
+		return DiscoveryPeer{}, false
+	}
+	master := peers[0]
+	for _, p := range peers[1:] {
+		if p.ID < master.ID {
+			master = p
+		}
+	}
+	return master, true
+}
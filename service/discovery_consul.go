@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+const (
+	consulCheckTTL   = "15s"
+	consulWatchRetry = time.Second * 2
+)
+
+// consulDiscovery implements PeerDiscovery on top of a Consul agent/cluster.
+type consulDiscovery struct {
+	client    *consul.Client
+	key       string
+	sessionID string
+	renewStop chan struct{}
+}
+
+func newConsulDiscovery(address, key string) (PeerDiscovery, error) {
+	cfg := consul.DefaultConfig()
+	if address != "" {
+		cfg.Address = address
+	}
+	client, err := consul.NewClient(cfg)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &consulDiscovery{client: client, key: key}, nil
+}
+
+// Register stores self under the cluster key in Consul's KV store, bound to
+// a session with a consulCheckTTL TTL check. As long as this process keeps
+// renewing the session the key stays; if it crashes, Consul invalidates the
+// session and deletes the key for us, the same role an etcd lease plays.
+func (d *consulDiscovery) Register(self DiscoveryPeer) error {
+	data, err := json.Marshal(self)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	sessionID, _, err := d.client.Session().Create(&consul.SessionEntry{
+		TTL:      consulCheckTTL,
+		Behavior: consul.SessionBehaviorDelete,
+	}, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	d.sessionID = sessionID
+
+	kv := d.client.KV()
+	acquired, _, err := kv.Acquire(&consul.KVPair{Key: d.peerKey(self.ID), Value: data, Session: sessionID}, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !acquired {
+		return maskAny(fmt.Errorf("Failed to acquire Consul session lock on %s", d.peerKey(self.ID)))
+	}
+
+	d.renewStop = make(chan struct{})
+	go d.client.Session().RenewPeriodic(consulCheckTTL, sessionID, nil, d.renewStop)
+	return nil
+}
+
+// Deregister stops renewing the session and removes this peer's key.
+func (d *consulDiscovery) Deregister(id string) error {
+	if d.renewStop != nil {
+		close(d.renewStop)
+		d.renewStop = nil
+	}
+	if _, err := d.client.KV().Delete(d.peerKey(id), nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Watch polls Consul's KV store using blocking queries and returns the
+// updated peer set whenever it changes, until stop is closed. On error
+// (e.g. Consul temporarily unreachable) it backs off by consulWatchRetry
+// instead of spinning.
+func (d *consulDiscovery) Watch(stop <-chan struct{}) (<-chan []DiscoveryPeer, error) {
+	out := make(chan []DiscoveryPeer)
+	go func() {
+		defer close(out)
+		var lastIndex uint64
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			pairs, meta, err := d.client.KV().List(d.key, &consul.QueryOptions{WaitIndex: lastIndex})
+			if err != nil {
+				select {
+				case <-time.After(consulWatchRetry):
+				case <-stop:
+					return
+				}
+				continue
+			}
+			lastIndex = meta.LastIndex
+			peers := make([]DiscoveryPeer, 0, len(pairs))
+			for _, kv := range pairs {
+				var p DiscoveryPeer
+				if err := json.Unmarshal(kv.Value, &p); err == nil {
+					peers = append(peers, p)
+				}
+			}
+			select {
+			case out <- peers:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (d *consulDiscovery) peerKey(id string) string {
+	return d.key + "/" + id
+}
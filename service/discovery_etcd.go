@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	etcd "github.com/coreos/etcd/clientv3"
+	"golang.org/x/net/context"
+)
+
+const (
+	etcdDialTimeout  = time.Second * 5
+	etcdLeaseTTL     = int64(20) // seconds
+	etcdKeepAlivePer = time.Second * 5
+)
+
+// etcdDiscovery implements PeerDiscovery on top of an etcd v3 cluster.
+type etcdDiscovery struct {
+	client  *etcd.Client
+	key     string
+	leaseID etcd.LeaseID
+}
+
+func newEtcdDiscovery(endpoints, key string) (PeerDiscovery, error) {
+	client, err := etcd.New(etcd.Config{
+		Endpoints:   strings.Split(endpoints, ","),
+		DialTimeout: etcdDialTimeout,
+	})
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &etcdDiscovery{client: client, key: key}, nil
+}
+
+// Register announces self under the cluster key, backed by an etcd lease
+// that is kept alive for as long as the process runs.
+func (d *etcdDiscovery) Register(self DiscoveryPeer) error {
+	lease, err := d.client.Grant(context.Background(), etcdLeaseTTL)
+	if err != nil {
+		return maskAny(err)
+	}
+	d.leaseID = lease.ID
+	data, err := json.Marshal(self)
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := d.client.Put(context.Background(), d.peerKey(self.ID), string(data), etcd.WithLease(lease.ID)); err != nil {
+		return maskAny(err)
+	}
+	keepAliveCh, err := d.client.KeepAlive(context.Background(), lease.ID)
+	if err != nil {
+		return maskAny(err)
+	}
+	go func() {
+		for range keepAliveCh {
+			// Drain responses, etcd client renews the lease for us.
+		}
+	}()
+	return nil
+}
+
+// Deregister removes this peer's key, letting it disappear from Watch.
+func (d *etcdDiscovery) Deregister(id string) error {
+	if _, err := d.client.Delete(context.Background(), d.peerKey(id)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Watch returns the current peer set and keeps pushing updates as peers are
+// registered or their lease expires, until stop is closed.
+func (d *etcdDiscovery) Watch(stop <-chan struct{}) (<-chan []DiscoveryPeer, error) {
+	out := make(chan []DiscoveryPeer)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	go func() {
+		defer close(out)
+		watchCh := d.client.Watch(ctx, d.key+"/", etcd.WithPrefix())
+		emit := func() {
+			peers, err := d.list(ctx)
+			if err == nil {
+				out <- peers
+			}
+		}
+		emit()
+		for range watchCh {
+			emit()
+		}
+	}()
+	return out, nil
+}
+
+func (d *etcdDiscovery) list(ctx context.Context) ([]DiscoveryPeer, error) {
+	resp, err := d.client.Get(ctx, d.key+"/", etcd.WithPrefix())
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	peers := make([]DiscoveryPeer, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var p DiscoveryPeer
+		if err := json.Unmarshal(kv.Value, &p); err == nil {
+			peers = append(peers, p)
+		}
+	}
+	return peers, nil
+}
+
+func (d *etcdDiscovery) peerKey(id string) string {
+	return d.key + "/" + id
+}
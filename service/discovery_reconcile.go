@@ -0,0 +1,96 @@
+package service
+
+// startDiscoveryReconcileLoop registers this peer with the configured
+// PeerDiscovery backend, elects the initial master and keeps myPeers in
+// sync with the registry so that a crash/restart (which loses in-memory
+// peer state) can repair itself without requiring --join again.
+func (s *Service) startDiscoveryReconcileLoop(stop <-chan struct{}) {
+	if s.discovery == nil {
+		return
+	}
+	self := DiscoveryPeer{
+		ID:       s.ID,
+		Address:  s.OwnAddress,
+		Port:     s.MasterPort,
+		DataDir:  s.DataDir,
+		HasAgent: true,
+	}
+	if err := s.discovery.Register(self); err != nil {
+		s.log.Errorf("Failed to register with discovery backend: %#v", err)
+		return
+	}
+	updates, err := s.discovery.Watch(stop)
+	if err != nil {
+		s.log.Errorf("Failed to watch discovery backend: %#v", err)
+		return
+	}
+	for peers := range updates {
+		s.reconcilePeers(peers)
+	}
+}
+
+// reconcilePeers updates s.myPeers based on the peer set currently known to
+// the discovery backend. While myPeers is still empty, it is built from the
+// registry: the lexicographically-smallest ID is elected as master and
+// placed first (the role helloHandler and sendMasterGoodbye use to find the
+// master), and this peer's state is set to master or slave accordingly.
+// Once myPeers is populated, the existing master is kept and any peer
+// discovery knows about but myPeers does not is added with a freshly
+// allocated PortOffset. This is what lets a
+// restarted starter repair its peer list from the registry instead of
+// needing --join again.
+func (s *Service) reconcilePeers(peers []DiscoveryPeer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	master, found := electMaster(peers)
+	if !found {
+		return
+	}
+
+	if len(s.myPeers.Peers) == 0 {
+		// Fresh process (or first run): bootstrap myPeers entirely from the
+		// discovery registry, master first.
+		s.myPeers.AgencySize = s.AgencySize
+		s.myPeers.Peers = append(s.myPeers.Peers, peerFromDiscovery(master, 0))
+		offset := 1
+		for _, p := range peers {
+			if p.ID == master.ID {
+				continue
+			}
+			s.myPeers.Peers = append(s.myPeers.Peers, peerFromDiscovery(p, offset))
+			offset++
+		}
+		if master.ID == s.ID {
+			s.state = stateMaster
+		} else {
+			s.state = stateSlave
+		}
+		s.log.Infof("Reconciled %d peers from discovery backend, master is '%s'", len(s.myPeers.Peers), master.ID)
+		return
+	}
+
+	// Already bootstrapped: add any peer discovery knows about that we lost
+	// track of (e.g. because we just restarted and /hello hasn't run yet).
+	for _, p := range peers {
+		if _, known := s.myPeers.PeerByID(p.ID); known {
+			continue
+		}
+		newPeer := peerFromDiscovery(p, s.myPeers.GetFreePortOffset())
+		s.myPeers.Peers = append(s.myPeers.Peers, newPeer)
+		s.log.Infof("Reconciled peer '%s' from discovery backend, portOffset: %d", newPeer.ID, newPeer.PortOffset)
+	}
+}
+
+// peerFromDiscovery converts a DiscoveryPeer into the Peer struct used by
+// myPeers, assigning it the given PortOffset.
+func peerFromDiscovery(p DiscoveryPeer, portOffset int) Peer {
+	return Peer{
+		ID:         p.ID,
+		Address:    p.Address,
+		Port:       p.Port,
+		PortOffset: portOffset,
+		DataDir:    p.DataDir,
+		HasAgent:   p.HasAgent,
+	}
+}
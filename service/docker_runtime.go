@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// dockerContainer wraps a docker container ID so it satisfies the Container
+// interface used throughout the service package.
+type dockerContainer struct {
+	client      *docker.Client
+	containerID string
+}
+
+func (c *dockerContainer) ProcessID() int {
+	info, err := c.client.InspectContainer(c.containerID)
+	if err != nil || info.State == nil {
+		return 0
+	}
+	return info.State.Pid
+}
+
+func (c *dockerContainer) ContainerID() string {
+	return c.containerID
+}
+
+// dockerRuntime implements ContainerRuntime on top of the Docker daemon,
+// the same backend the starter has always used via --dockerEndpoint.
+type dockerRuntime struct {
+	client *docker.Client
+}
+
+// newDockerRuntime creates a ContainerRuntime that talks to the Docker
+// daemon at config.DockerEndpoint.
+func newDockerRuntime(config ServiceConfig) (ContainerRuntime, error) {
+	client, err := docker.NewClient(config.DockerEndpoint)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &dockerRuntime{client: client}, nil
+}
+
+// Create creates (but does not start) a container for the given config.
+func (r *dockerRuntime) Create(config ContainerConfig) (Container, error) {
+	binds := make([]string, 0, len(config.Volumes))
+	for host, container := range config.Volumes {
+		binds = append(binds, fmt.Sprintf("%s:%s", host, container))
+	}
+	networkMode := ""
+	if config.NetHost {
+		networkMode = "host"
+	}
+
+	exposedPorts := make(map[docker.Port]struct{}, len(config.Ports))
+	portBindings := make(map[docker.Port][]docker.PortBinding, len(config.Ports))
+	for containerPort, hostPort := range config.Ports {
+		p := docker.Port(fmt.Sprintf("%d/tcp", containerPort))
+		exposedPorts[p] = struct{}{}
+		portBindings[p] = []docker.PortBinding{{HostPort: strconv.Itoa(hostPort)}}
+	}
+
+	c, err := r.client.CreateContainer(docker.CreateContainerOptions{
+		Name: config.ContainerID,
+		Config: &docker.Config{
+			Image:        config.Image,
+			Cmd:          append([]string{config.Command}, config.Args...),
+			User:         config.User,
+			ExposedPorts: exposedPorts,
+		},
+		HostConfig: &docker.HostConfig{
+			Binds:        binds,
+			NetworkMode:  networkMode,
+			Privileged:   config.Privileged,
+			PortBindings: portBindings,
+		},
+	})
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &dockerContainer{client: r.client, containerID: c.ID}, nil
+}
+
+// Start starts a previously created container.
+func (r *dockerRuntime) Start(containerID string) error {
+	if err := r.client.StartContainer(containerID, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Stop stops a running container, giving it up to timeout to terminate.
+func (r *dockerRuntime) Stop(containerID string, timeout time.Duration) error {
+	if err := r.client.StopContainer(containerID, uint(timeout.Seconds())); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Inspect returns the current state of a container.
+func (r *dockerRuntime) Inspect(containerID string) (ContainerInfo, error) {
+	info, err := r.client.InspectContainer(containerID)
+	if err != nil {
+		return ContainerInfo{}, maskAny(err)
+	}
+	result := ContainerInfo{ContainerID: containerID}
+	if info.State != nil {
+		result.Running = info.State.Running
+		result.ExitCode = info.State.ExitCode
+	}
+	return result, nil
+}
+
+// Logs returns a reader over the container's combined stdout/stderr.
+func (r *dockerRuntime) Logs(containerID string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		err := r.client.Logs(docker.LogsOptions{
+			Container:    containerID,
+			OutputStream: pw,
+			ErrorStream:  pw,
+			Stdout:       true,
+			Stderr:       true,
+			Follow:       false,
+		})
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// Remove removes a stopped container.
+func (r *dockerRuntime) Remove(containerID string) error {
+	if err := r.client.RemoveContainer(docker.RemoveContainerOptions{ID: containerID}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
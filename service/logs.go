@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	logTailChunkSize  = 8 * 1024
+	logFollowInterval = 500 * time.Millisecond
+)
+
+// arangodLogTimestampLayout is the timestamp format arangod prefixes its log
+// lines with, e.g. "2017-11-02T14:23:01Z [12345] INFO ...".
+const arangodLogTimestampLayout = "2006-01-02T15:04:05Z"
+
+// seekTailOffset returns the byte offset in f from which reading to the end
+// yields (at most) the last n lines, by reading backwards in fixed-size
+// chunks and counting newlines. n<=0 means "no tail requested", i.e. stream
+// the whole file from the start, matching `docker logs` without --tail.
+func seekTailOffset(f *os.File, n int) (int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	if n <= 0 {
+		return 0, nil
+	}
+
+	var (
+		pos      = size
+		newlines = 0
+		buf      = make([]byte, logTailChunkSize)
+	)
+	if size > 0 {
+		// An unterminated last line counts as one of the n lines.
+		if _, err := f.ReadAt(buf[:1], size-1); err != nil {
+			return 0, maskAny(err)
+		}
+		if buf[0] != '\n' {
+			newlines = 1
+		}
+	}
+	for pos > 0 && newlines <= n {
+		readSize := int64(logTailChunkSize)
+		if readSize > pos {
+			readSize = pos
+		}
+		pos -= readSize
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return 0, maskAny(err)
+		}
+		if _, err := io.ReadFull(f, buf[:readSize]); err != nil {
+			return 0, maskAny(err)
+		}
+		for i := int(readSize) - 1; i >= 0; i-- {
+			if buf[i] == '\n' {
+				newlines++
+				if newlines > n {
+					pos += int64(i) + 1
+					break
+				}
+			}
+		}
+	}
+	return pos, nil
+}
+
+// parseLogLineTime extracts the leading RFC3339-ish timestamp arangod emits
+// at the start of every log line. The zero time is returned if none is found.
+func parseLogLineTime(line string) time.Time {
+	if len(line) < len(arangodLogTimestampLayout) {
+		return time.Time{}
+	}
+	t, err := time.Parse(arangodLogTimestampLayout, line[:len(arangodLogTimestampLayout)])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// streamLogFile writes logPath to w, honouring the tail, follow and since
+// query parameters of r:
+//   - tail=N starts N lines from the end instead of at the beginning.
+//   - since=<RFC3339> drops lines older than the given time.
+//   - follow=1 keeps the response open and streams new lines as they are
+//     appended, until the request's context is cancelled.
+func streamLogFile(w http.ResponseWriter, r *http.Request, logPath string) {
+	f, err := os.Open(logPath)
+	if os.IsNotExist(err) {
+		// Log file not there (yet), we allow this.
+		w.WriteHeader(http.StatusOK)
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer f.Close()
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			since = t
+		}
+	}
+	follow := r.URL.Query().Get("follow") == "1"
+	tail := 0
+	if s := r.URL.Query().Get("tail"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil {
+			tail = n
+		}
+	}
+
+	offset, err := seekTailOffset(f, tail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	header := w.Header()
+	header.Set("Transfer-Encoding", "chunked")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	flusher, canFlush := w.(http.Flusher)
+
+	writeLine := func(line string) bool {
+		if !since.IsZero() {
+			if t := parseLogLineTime(line); !t.IsZero() && t.Before(since) {
+				return true
+			}
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return false
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if !writeLine(line) {
+				return
+			}
+		}
+		if err == io.EOF {
+			if !follow {
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(logFollowInterval):
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// masterClient returns an *http.Client for calling another peer's
+// coordination endpoints. When TLS is configured it trusts the shared CA
+// (s.tlsCAFile) and presents this peer's own certificate, so it satisfies
+// --tls.clientAuth=require on the other end.
+func (s *Service) masterClient() *http.Client {
+	if !s.TLSConfig.Enabled() {
+		return http.DefaultClient
+	}
+	tlsCfg := &tls.Config{}
+	if s.tlsCAFile != "" {
+		if caPEM, err := ioutil.ReadFile(s.tlsCAFile); err == nil {
+			pool := x509.NewCertPool()
+			if pool.AppendCertsFromPEM(caPEM) {
+				tlsCfg.RootCAs = pool
+			}
+		}
+	}
+	if cert, err := tls.LoadX509KeyPair(s.TLSConfig.CertFile, s.TLSConfig.KeyFile); err == nil {
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+}
+
+// sendMasterGoodbye tells the current master that this peer is leaving for
+// good, so it can remove it from myPeers and deregister it from discovery.
+// It authenticates the same way the master requires of /goodbye: a bearer
+// token derived from --auth.jwtSecret.
+func (s *Service) sendMasterGoodbye() error {
+	s.mutex.Lock()
+	var master Peer
+	haveMaster := len(s.myPeers.Peers) > 0
+	if haveMaster {
+		master = s.myPeers.Peers[0]
+	}
+	s.mutex.Unlock()
+
+	if !haveMaster || master.ID == s.ID {
+		// No master known, or we are the master: nothing to say goodbye to.
+		return nil
+	}
+
+	scheme := master.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	url := fmt.Sprintf("%s://%s:%d/goodbye", scheme, master.Address, master.Port)
+	body, err := json.Marshal(GoodbyeRequest{SlaveID: s.ID})
+	if err != nil {
+		return maskAny(err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return maskAny(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.AuthJWTSecret != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken(s.AuthJWTSecret))
+	}
+
+	resp, err := s.masterClient().Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return maskAny(fmt.Errorf("Master returned status %d for /goodbye", resp.StatusCode))
+	}
+	return nil
+}
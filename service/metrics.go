@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics bundles all Prometheus collectors exposed by the starter on
+// /metrics. A custom registry is used so we don't leak the Go runtime
+// collectors unless --metrics.goCollector is set.
+type metrics struct {
+	registry *prometheus.Registry
+
+	peerReachable  *prometheus.GaugeVec
+	childUp        *prometheus.GaugeVec
+	childRestarts  *prometheus.CounterVec
+	childLastExit  *prometheus.GaugeVec
+	peersTotal     prometheus.Gauge
+	agencySize     prometheus.Gauge
+	serversStarted prometheus.Gauge
+	helloDuration  prometheus.Histogram
+}
+
+// newMetrics creates the collectors and registers them with a fresh registry.
+// When goCollector is true, the standard Go runtime/process collectors are
+// added as well.
+func newMetrics(goCollector bool) *metrics {
+	registry := prometheus.NewRegistry()
+	if goCollector {
+		registry.MustRegister(prometheus.NewGoCollector())
+		registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	}
+
+	m := &metrics{
+		registry: registry,
+		peerReachable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "arangodb_peer_reachable",
+			Help: "Whether a peer responded to /version (1) or not (0).",
+		}, []string{"peer"}),
+		childUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "arangodb_child_up",
+			Help: "Whether a child server process is currently running.",
+		}, []string{"type"}),
+		childRestarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "arangodb_child_restarts_total",
+			Help: "Number of times a child server process has been restarted.",
+		}, []string{"type"}),
+		childLastExit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "arangodb_child_last_exit_code",
+			Help: "Exit code of the last terminated child server process.",
+		}, []string{"type"}),
+		peersTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "arangodb_peers_total",
+			Help: "Number of peers currently known to this starter.",
+		}),
+		agencySize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "arangodb_agency_size",
+			Help: "Configured agency size of the cluster.",
+		}),
+		serversStarted: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "arangodb_servers_started",
+			Help: "Whether all expected servers for this peer have been started.",
+		}),
+		helloDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "arangodb_hello_handler_duration_seconds",
+			Help:    "Duration of /hello handler invocations.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+	registry.MustRegister(m.peerReachable, m.childUp, m.childRestarts, m.childLastExit,
+		m.peersTotal, m.agencySize, m.serversStarted, m.helloDuration)
+	return m
+}
+
+// metricsHandler returns the Prometheus text-format exposition for m.
+func (m *metrics) metricsHandler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// updateChildMetrics records the up/down state of each server type in
+// arangodb_child_up, based on the servers currently reported by /process.
+func (s *Service) updateChildMetrics(running []ServerProcess) {
+	up := map[string]bool{"agent": false, "coordinator": false, "dbserver": false}
+	for _, p := range running {
+		up[p.Type] = true
+	}
+	for serverType, isUp := range up {
+		value := 0.0
+		if isUp {
+			value = 1.0
+		}
+		s.metrics.childUp.WithLabelValues(serverType).Set(value)
+	}
+}
+
+// pollPeerReachability periodically GETs /version on every known peer and
+// records whether it responded, until stop is closed.
+func (s *Service) pollPeerReachability(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second * 10)
+	defer ticker.Stop()
+	client := &http.Client{Timeout: time.Second * 5}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			peers := append([]Peer(nil), s.myPeers.Peers...)
+			s.mutex.Unlock()
+
+			s.metrics.peersTotal.Set(float64(len(peers)))
+			s.metrics.agencySize.Set(float64(s.AgencySize))
+			for _, p := range peers {
+				scheme := p.Scheme
+				if scheme == "" {
+					scheme = "http"
+				}
+				url := fmt.Sprintf("%s://%s:%d/version", scheme, p.Address, p.Port)
+				resp, err := client.Get(url)
+				reachable := 0.0
+				if err == nil {
+					resp.Body.Close()
+					if resp.StatusCode == http.StatusOK {
+						reachable = 1.0
+					}
+				}
+				s.metrics.peerReachable.WithLabelValues(p.ID).Set(reachable)
+			}
+		}
+	}
+}
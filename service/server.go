@@ -1,13 +1,13 @@
 package service
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"path/filepath"
+	"time"
 )
 
 type HelloRequest struct {
@@ -15,6 +15,7 @@ type HelloRequest struct {
 	SlaveAddress string // Address used to reach the slave (if empty, this will be derived from the request)
 	SlavePort    int    // Port used to reach the slave
 	DataDir      string // Directory used for data by this slave
+	SlaveScheme  string // Scheme ("http" or "https") under which the slave is reachable
 }
 
 type GoodbyeRequest struct {
@@ -42,14 +43,45 @@ type ServerProcess struct {
 // startHTTPServer initializes and runs the HTTP server.
 // If will return directly after starting it.
 func (s *Service) startHTTPServer() {
-	http.HandleFunc("/hello", s.helloHandler)
-	http.HandleFunc("/goodbye", s.goodbyeHandler)
-	http.HandleFunc("/process", s.processListHandler)
-	http.HandleFunc("/logs/agent", s.agentLogsHandler)
-	http.HandleFunc("/logs/dbserver", s.dbserverLogsHandler)
-	http.HandleFunc("/logs/coordinator", s.coordinatorLogsHandler)
-	http.HandleFunc("/version", s.versionHandler)
-	http.HandleFunc("/shutdown", s.shutdownHandler)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", requireBearerToken(s.AuthJWTSecret, s.helloHandler))
+	mux.HandleFunc("/goodbye", requireBearerToken(s.AuthJWTSecret, s.goodbyeHandler))
+	mux.HandleFunc("/process", s.processListHandler)
+	mux.HandleFunc("/logs/agent", s.agentLogsHandler)
+	mux.HandleFunc("/logs/dbserver", s.dbserverLogsHandler)
+	mux.HandleFunc("/logs/coordinator", s.coordinatorLogsHandler)
+	mux.HandleFunc("/version", s.versionHandler)
+	mux.HandleFunc("/shutdown", requireBearerToken(s.AuthJWTSecret, s.shutdownHandler))
+
+	if s.MetricsAddr == "" {
+		mux.Handle("/metrics", s.metrics.metricsHandler())
+	} else {
+		go func() {
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", s.metrics.metricsHandler())
+			s.log.Infof("Listening for metrics on %s", s.MetricsAddr)
+			if err := http.ListenAndServe(s.MetricsAddr, metricsMux); err != nil {
+				s.log.Errorf("Failed to listen for metrics on %s: %v", s.MetricsAddr, err)
+			}
+		}()
+	}
+
+	srv := &http.Server{Handler: mux}
+
+	if s.discovery == nil {
+		disc, err := NewPeerDiscovery(s.Discovery)
+		if err != nil {
+			s.log.Errorf("Failed to create discovery backend: %#v", err)
+		} else {
+			s.discovery = disc
+		}
+	}
+
+	stop := make(chan struct{})
+	go s.startContainerGCLoop(stop)
+	go s.startChildMonitorLoop(stop)
+	go s.pollPeerReachability(stop)
+	go s.startDiscoveryReconcileLoop(stop)
 
 	go func() {
 		containerPort, hostPort, err := s.getHTTPServerPort()
@@ -57,8 +89,34 @@ func (s *Service) startHTTPServer() {
 			s.log.Fatalf("Failed to get HTTP port info: %#v", err)
 		}
 		addr := fmt.Sprintf("0.0.0.0:%d", containerPort)
+		srv.Addr = addr
+
+		tlsCfg := s.TLSConfig
+		if tlsCfg.AutoGenerate {
+			certFile, keyFile, caFile, err := autoGenerateTLS(s.DataDir, s.ID, s.OwnAddress)
+			if err != nil {
+				s.log.Fatalf("Failed to auto-generate TLS certificates: %#v", err)
+			}
+			tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile = certFile, keyFile, caFile
+			s.TLSConfig.CertFile, s.TLSConfig.KeyFile = certFile, keyFile
+			s.tlsCAFile = caFile
+		}
+		if tlsCfg.Enabled() {
+			cfg, err := createServerTLSConfig(tlsCfg)
+			if err != nil {
+				s.log.Fatalf("Failed to build TLS config: %#v", err)
+			}
+			srv.TLSConfig = cfg
+			s.scheme = "https"
+			s.log.Infof("Listening on %s (%s:%d) with TLS", addr, s.OwnAddress, hostPort)
+			if err := srv.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile); err != nil {
+				s.log.Errorf("Failed to listen on %s: %v", addr, err)
+			}
+			return
+		}
+		s.scheme = "http"
 		s.log.Infof("Listening on %s (%s:%d)", addr, s.OwnAddress, hostPort)
-		if err := http.ListenAndServe(addr, nil); err != nil {
+		if err := srv.ListenAndServe(); err != nil {
 			s.log.Errorf("Failed to listen on %s: %v", addr, err)
 		}
 	}()
@@ -67,6 +125,9 @@ func (s *Service) startHTTPServer() {
 // HTTP service function:
 
 func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	defer func() { s.metrics.helloDuration.Observe(time.Since(start).Seconds()) }()
+
 	// Claim exclusive access to our data structures
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -76,7 +137,11 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 		header := w.Header()
 		if len(s.myPeers.Peers) > 0 {
 			master := s.myPeers.Peers[0]
-			header.Add("Location", fmt.Sprintf("http://%s:%d/hello", master.Address, master.Port))
+			scheme := master.Scheme
+			if scheme == "" {
+				scheme = "http"
+			}
+			header.Add("Location", fmt.Sprintf("%s://%s:%d/hello", scheme, master.Address, master.Port))
 			w.WriteHeader(http.StatusTemporaryRedirect)
 		} else {
 			writeError(w, http.StatusBadRequest, "No master known.")
@@ -96,6 +161,7 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 				PortOffset: 0,
 				DataDir:    s.DataDir,
 				HasAgent:   true,
+				Scheme:     s.scheme,
 			},
 		}
 		s.myPeers.AgencySize = s.AgencySize
@@ -126,6 +192,16 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// When mutual TLS is in effect, make sure the certificate CN matches
+		// the claimed SlaveID, so a compromised node cannot impersonate or
+		// evict an arbitrary peer.
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			if !peerIDMatchesCertificate(r.TLS.PeerCertificates, req.SlaveID) {
+				writeError(w, http.StatusForbidden, "Client certificate CN does not match SlaveID.")
+				return
+			}
+		}
+
 		// Check datadir
 		if !s.allowSameDataDir {
 			for _, p := range s.myPeers.Peers {
@@ -156,6 +232,7 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 				PortOffset: s.myPeers.GetFreePortOffset(),
 				DataDir:    req.DataDir,
 				HasAgent:   len(s.myPeers.Peers) < s.AgencySize,
+				Scheme:     req.SlaveScheme,
 			}
 			s.myPeers.Peers = append(s.myPeers.Peers, newPeer)
 			s.log.Infof("Added new peer '%s': %s, portOffset: %d", newPeer.ID, newPeer.Address, newPeer.PortOffset)
@@ -164,6 +241,14 @@ func (s *Service) helloHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	if s.tlsCAFile != "" {
+		// net/http replaces \n/\r in header values with spaces, which would
+		// corrupt a raw multi-line PEM; base64-encode it instead so the
+		// receiving peer can decode and pem.Decode it intact.
+		if ca, err := ioutil.ReadFile(s.tlsCAFile); err == nil {
+			w.Header().Set("X-TLS-CA", base64.StdEncoding.EncodeToString(ca))
+		}
+	}
 	b, err := json.Marshal(s.myPeers)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -200,6 +285,14 @@ func (s *Service) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// When mutual TLS is in effect, only let a peer evict itself.
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if !peerIDMatchesCertificate(r.TLS.PeerCertificates, req.SlaveID) {
+			writeError(w, http.StatusForbidden, "Client certificate CN does not match SlaveID.")
+			return
+		}
+	}
+
 	// Remove the peer
 	s.log.Infof("Removing peer %s", req.SlaveID)
 	if removed := s.myPeers.RemovePeerByID(req.SlaveID); !removed {
@@ -207,6 +300,11 @@ func (s *Service) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "Unknown ID")
 		return
 	}
+	if s.discovery != nil {
+		if err := s.discovery.Deregister(req.SlaveID); err != nil {
+			s.log.Warningf("Failed to deregister peer %s from discovery backend: %#v", req.SlaveID, err)
+		}
+	}
 
 	// Peer has been removed, update stored config
 	s.log.Info("Saving setup")
@@ -256,8 +354,14 @@ func (s *Service) processListHandler(w http.ResponseWriter, r *http.Request) {
 				ContainerID: p.ContainerID(),
 			})
 		}
+		s.updateChildMetrics(resp.Servers)
 	}
 	resp.ServersStarted = len(resp.Servers) == expectedServers
+	if resp.ServersStarted {
+		s.metrics.serversStarted.Set(1)
+	} else {
+		s.metrics.serversStarted.Set(0)
+	}
 	b, err := json.Marshal(resp)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -286,6 +390,8 @@ func (s *Service) coordinatorLogsHandler(w http.ResponseWriter, r *http.Request)
 	s.logsHandler(w, r, "coordinator", portOffsetCoordinator)
 }
 
+// logsHandler serves the log file of the given server type, supporting
+// ?tail=N, ?follow=1 and ?since=<RFC3339> the same way `docker logs -f` does.
 func (s *Service) logsHandler(w http.ResponseWriter, r *http.Request, mode string, serverPortOffset int) {
 	myPeer, found := s.myPeers.PeerByID(s.ID)
 	if !found {
@@ -298,20 +404,7 @@ func (s *Service) logsHandler(w http.ResponseWriter, r *http.Request, mode strin
 	myPort := s.MasterPort + portOffset + serverPortOffset
 	logPath := filepath.Join(s.DataDir, fmt.Sprintf("%s%d", mode, myPort), "arangod.log")
 	s.log.Debugf("Fetching logs in %s", logPath)
-	rd, err := os.Open(logPath)
-	if os.IsNotExist(err) {
-		// Log file not there (yet), we allow this
-		w.WriteHeader(http.StatusOK)
-	} else if err != nil {
-		s.log.Errorf("Failed to open log file '%s': %#v", logPath, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
-	} else {
-		// Log open
-		defer rd.Close()
-		w.WriteHeader(http.StatusOK)
-		io.Copy(w, rd)
-	}
+	streamLogFile(w, r, logPath)
 }
 
 // versionHandler returns a JSON object containing the current version & build number.
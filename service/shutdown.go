@@ -0,0 +1,45 @@
+package service
+
+import "time"
+
+// GracefulStop attempts an orderly shutdown: it informs the master that this
+// peer is leaving, waits up to timeout for the agent/coordinator/dbserver
+// child processes it started to exit on their own, and persists the current
+// setup so a restart can pick up where it left off. It is invoked from the
+// first termination signal; a second signal calls HardStop instead of
+// waiting for this to finish.
+func (s *Service) GracefulStop(timeout time.Duration) {
+	s.log.Info("Graceful shutdown started")
+	if err := s.sendMasterGoodbye(); err != nil {
+		s.log.Warningf("Failed to send master goodbye: %#v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.servers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.log.Warningf("Timed out after %s waiting for child processes to stop, forcing shutdown", timeout)
+		s.HardStop()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.saveSetup(); err != nil {
+		s.log.Errorf("Failed to save setup during shutdown: %#v", err)
+	}
+}
+
+// HardStop sends SIGTERM directly to all child processes without waiting for
+// them to exit on their own. It is invoked by a second termination signal,
+// and also by GracefulStop itself on timeout, so the two calls could
+// otherwise race; s.hardStopOnce makes the actual stop run exactly once.
+func (s *Service) HardStop() {
+	s.hardStopOnce.Do(func() {
+		s.log.Info("Forcing shutdown of child processes")
+		s.servers.Stop()
+	})
+}
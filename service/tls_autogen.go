@@ -0,0 +1,175 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	autoGeneratedKeyBits = 2048
+	autoGeneratedCAValid = time.Hour * 24 * 365 * 5
+)
+
+// autoGenerateTLS creates a certificate for this peer (and, unless one is
+// already present, a self-signed CA to sign it with), writing both into
+// dataDir so that --tls.autoGenerate needs no further operator setup. The CA
+// is handed to joining peers through the /hello response's X-TLS-CA header;
+// an operator (or future --join logic) can place that CA's cert and key into
+// a peer's dataDir before its first start so the whole cluster ends up
+// trusting the same CA instead of each peer minting its own.
+func autoGenerateTLS(dataDir, peerID, ownAddress string) (certFile, keyFile, caFile string, err error) {
+	caFile = filepath.Join(dataDir, "tls-ca.crt")
+	caKeyFile := filepath.Join(dataDir, "tls-ca.key")
+	certFile = filepath.Join(dataDir, "tls.crt")
+	keyFile = filepath.Join(dataDir, "tls.key")
+
+	if _, statErr := os.Stat(certFile); statErr == nil {
+		// Already generated on a previous run.
+		return certFile, keyFile, caFile, nil
+	}
+
+	caKey, caCert, err := loadOrGenerateCA(caFile, caKeyFile)
+	if err != nil {
+		return "", "", "", maskAny(err)
+	}
+
+	peerKey, peerCertDER, err := generateSignedCert(peerID, ownAddress, caCert, caKey)
+	if err != nil {
+		return "", "", "", maskAny(err)
+	}
+	if err := writeCertAndKey(certFile, keyFile, peerCertDER, peerKey); err != nil {
+		return "", "", "", maskAny(err)
+	}
+	return certFile, keyFile, caFile, nil
+}
+
+// loadOrGenerateCA loads the CA at caFile/caKeyFile if both are already
+// present -- e.g. copied in from another peer's dataDir so the cluster
+// shares one CA -- and otherwise mints a fresh self-signed CA and writes it
+// to caFile/caKeyFile (the first peer to auto-generate TLS in a cluster).
+func loadOrGenerateCA(caFile, caKeyFile string) (*rsa.PrivateKey, []byte, error) {
+	if _, err := os.Stat(caFile); err == nil {
+		if _, err := os.Stat(caKeyFile); err == nil {
+			return readCertAndKey(caFile, caKeyFile)
+		}
+	}
+	key, der, err := generateSelfSignedCA()
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	if err := writeCertAndKey(caFile, caKeyFile, der, key); err != nil {
+		return nil, nil, maskAny(err)
+	}
+	return key, der, nil
+}
+
+func generateSelfSignedCA() (*rsa.PrivateKey, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, autoGeneratedKeyBits)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "arangodb-starter-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(autoGeneratedCAValid),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	return key, der, nil
+}
+
+// generateSignedCert creates a leaf certificate for commonName, signed by
+// caCertDER/caKey. ownAddress is added as a SAN (DNSName or IPAddress,
+// whichever it parses as) alongside "localhost"/127.0.0.1, since Go clients
+// verify the hostname against SANs, not the legacy CommonName field.
+func generateSignedCert(commonName, ownAddress string, caCertDER []byte, caKey *rsa.PrivateKey) (*rsa.PrivateKey, []byte, error) {
+	caCert, err := x509.ParseCertificate(caCertDER)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, autoGeneratedKeyBits)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(autoGeneratedCAValid),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if ip := net.ParseIP(ownAddress); ip != nil {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if ownAddress != "" {
+		template.DNSNames = append(template.DNSNames, ownAddress)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	return key, der, nil
+}
+
+func writeCertAndKey(certFile, keyFile string, certDER []byte, key *rsa.PrivateKey) error {
+	certOut, err := os.OpenFile(certFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
+		return maskAny(err)
+	}
+
+	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer keyOut.Close()
+	return pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// readCertAndKey loads a certificate and private key previously written by
+// writeCertAndKey.
+func readCertAndKey(certFile, keyFile string) (*rsa.PrivateKey, []byte, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, maskAny(fmt.Errorf("Failed to decode PEM certificate from %s", certFile))
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, maskAny(fmt.Errorf("Failed to decode PEM private key from %s", keyFile))
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	return key, certBlock.Bytes, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig holds the --tls.* flags that configure the peer coordination
+// HTTP server.
+type TLSConfig struct {
+	CertFile     string // --tls.cert
+	KeyFile      string // --tls.key
+	CAFile       string // --tls.ca
+	ClientAuth   string // --tls.clientAuth: none|require
+	AutoGenerate bool   // --tls.autoGenerate
+}
+
+// Enabled returns true if enough information was given to run the
+// coordination server over TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.AutoGenerate
+}
+
+// createServerTLSConfig builds a *tls.Config for startHTTPServer out of the
+// configured cert/key/CA files. When ClientAuth is "require", client
+// certificates are verified against CAFile and the peer's CN is checked
+// against its SlaveID in helloHandler/goodbyeHandler.
+func createServerTLSConfig(c TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if c.CAFile != "" {
+		caCert, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, maskAny(fmt.Errorf("Failed to parse CA certificate from %s", c.CAFile))
+		}
+		cfg.ClientCAs = pool
+	}
+	switch c.ClientAuth {
+	case "", "none":
+		cfg.ClientAuth = tls.NoClientCert
+	case "require":
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, maskAny(fmt.Errorf("Unknown --tls.clientAuth value '%s'", c.ClientAuth))
+	}
+	return cfg, nil
+}
+
+// peerIDMatchesCertificate checks that the CN of the leaf client certificate
+// (the first entry of certs) matches the given peer ID, so a compromised node cannot
+// evict or impersonate arbitrary peers over /hello and /goodbye.
+func peerIDMatchesCertificate(certs []*x509.Certificate, peerID string) bool {
+	if len(certs) == 0 {
+		return false
+	}
+	return certs[0].Subject.CommonName == peerID
+}